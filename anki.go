@@ -0,0 +1,311 @@
+package main
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"go/doc"
+	"go/token"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ankiExporter writes a self-contained Anki deck: a zipped
+// collection.anki2 SQLite database, plus the empty media manifest Anki
+// expects alongside it in a .apkg file.
+type ankiExporter struct {
+	deckName string
+}
+
+func (e ankiExporter) Export(fset *token.FileSet, pkg *doc.Package, w io.Writer) error {
+	cards, err := cardsFromPackage(fset, pkg)
+	if err != nil {
+		return err
+	}
+
+	dbFile, err := ioutil.TempFile("", "gocards-*.anki2")
+	if err != nil {
+		return err
+	}
+	dbPath := dbFile.Name()
+	dbFile.Close()
+	defer os.Remove(dbPath)
+
+	if err := writeAnkiCollection(dbPath, pkg.ImportPath, e.deckName, cards); err != nil {
+		return err
+	}
+
+	dbBytes, err := ioutil.ReadFile(dbPath)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+
+	cf, err := zw.Create("collection.anki2")
+	if err != nil {
+		return err
+	}
+	if _, err := cf.Write(dbBytes); err != nil {
+		return err
+	}
+
+	// Anki requires a "media" file listing imported media by numeric
+	// name; this deck has none.
+	mf, err := zw.Create("media")
+	if err != nil {
+		return err
+	}
+	if _, err := mf.Write([]byte("{}")); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// writeAnkiCollection creates a fresh anki2 SQLite database at path
+// containing the named deck (alongside Anki's "Default" deck, id 1) and
+// one note per card.
+func writeAnkiCollection(path, importPath, deckName string, cards []card) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(ankiSchema); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	modelID := now.Unix()
+	deckID := modelID + 1
+
+	conf, err := json.Marshal(ankiConf{CurDeck: deckID, ActiveDecks: []int64{deckID}})
+	if err != nil {
+		return err
+	}
+	models, err := json.Marshal(map[string]ankiModel{
+		strconv.FormatInt(modelID, 10): newAnkiModel(modelID, deckID),
+	})
+	if err != nil {
+		return err
+	}
+	decks, err := json.Marshal(map[string]ankiDeck{
+		"1":                            defaultAnkiDeck(1, "Default"),
+		strconv.FormatInt(deckID, 10): defaultAnkiDeck(deckID, deckName),
+	})
+	if err != nil {
+		return err
+	}
+	dconf, err := json.Marshal(map[string]ankiDeckConf{
+		"1": defaultAnkiDeckConf(1),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`insert into col
+		(id, crt, mod, scm, ver, dty, usn, ls, conf, models, decks, dconf, tags)
+		values (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		now.Unix(), now.UnixNano()/1e6, now.UnixNano()/1e6, conf, models, decks, dconf)
+	if err != nil {
+		return err
+	}
+
+	for i, c := range cards {
+		noteID := modelID + 1000 + int64(i)
+		cardID := modelID + 2000 + int64(i)
+		guid := noteGUID(importPath, c.Symbol)
+		flds := c.Front + "\x1f" + c.Back + "\x1f" + c.Signature + "\x1f" + c.Code
+
+		_, err = db.Exec(`insert into notes
+			(id, guid, mid, mod, usn, tags, flds, sfld, csum, flags, data)
+			values (?, ?, ?, ?, -1, '', ?, ?, 0, 0, '')`,
+			noteID, guid, modelID, now.Unix(), flds, c.Front)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Exec(`insert into cards
+			(id, nid, did, ord, mod, usn, type, queue, due, ivl, factor, reps, lapses, left, odue, odid, flags, data)
+			values (?, ?, ?, 0, ?, -1, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')`,
+			cardID, noteID, deckID, now.Unix(), i+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// noteGUID derives a stable note GUID from a symbol's import path and
+// name, so re-running the exporter over an unchanged symbol updates its
+// existing note on import instead of creating a duplicate.
+func noteGUID(importPath, symbol string) string {
+	h := fnv.New64a()
+	io.WriteString(h, importPath)
+	io.WriteString(h, "\x1f")
+	io.WriteString(h, symbol)
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+// The tables below mirror the anki2 schema Anki itself creates for a new
+// collection, trimmed to what a freshly imported deck needs.
+const ankiSchema = `
+create table col (
+	id     integer primary key,
+	crt    integer not null,
+	mod    integer not null,
+	scm    integer not null,
+	ver    integer not null,
+	dty    integer not null,
+	usn    integer not null,
+	ls     integer not null,
+	conf   text not null,
+	models text not null,
+	decks  text not null,
+	dconf  text not null,
+	tags   text not null
+);
+create table notes (
+	id    integer primary key,
+	guid  text not null,
+	mid   integer not null,
+	mod   integer not null,
+	usn   integer not null,
+	tags  text not null,
+	flds  text not null,
+	sfld  text not null,
+	csum  integer not null,
+	flags integer not null,
+	data  text not null
+);
+create table cards (
+	id     integer primary key,
+	nid    integer not null,
+	did    integer not null,
+	ord    integer not null,
+	mod    integer not null,
+	usn    integer not null,
+	type   integer not null,
+	queue  integer not null,
+	due    integer not null,
+	ivl    integer not null,
+	factor integer not null,
+	reps   integer not null,
+	lapses integer not null,
+	left   integer not null,
+	odue   integer not null,
+	odid   integer not null,
+	flags  integer not null,
+	data   text not null
+);
+create table revlog (
+	id      integer primary key,
+	cid     integer not null,
+	usn     integer not null,
+	ease    integer not null,
+	ivl     integer not null,
+	lastIvl integer not null,
+	factor  integer not null,
+	time    integer not null,
+	type    integer not null
+);
+create table graves (
+	usn  integer not null,
+	oid  integer not null,
+	type integer not null
+);
+create index ix_notes_usn on notes (usn);
+create index ix_cards_usn on cards (usn);
+create index ix_cards_nid on cards (nid);
+create index ix_cards_sched on cards (did, queue, due);
+`
+
+// ankiConf is the top-level "conf" blob stored in the col table.
+type ankiConf struct {
+	CurDeck     int64   `json:"curDeck"`
+	ActiveDecks []int64 `json:"activeDecks"`
+}
+
+// ankiModel is one entry of the "models" blob: a note type with the
+// Front/Back/Signature/Code fields this exporter writes into notes.flds.
+type ankiModel struct {
+	ID     int64           `json:"id"`
+	Name   string          `json:"name"`
+	Type   int             `json:"type"`
+	Mod    int64           `json:"mod"`
+	Usn    int             `json:"usn"`
+	Sortf  int             `json:"sortf"`
+	Did    int64           `json:"did"`
+	Flds   []ankiField     `json:"flds"`
+	Tmpls  []ankiTemplate  `json:"tmpls"`
+	CSS    string          `json:"css"`
+	Req    [][]interface{} `json:"req"`
+}
+
+type ankiField struct {
+	Name string `json:"name"`
+	Ord  int    `json:"ord"`
+}
+
+type ankiTemplate struct {
+	Name string `json:"name"`
+	Ord  int    `json:"ord"`
+	Qfmt string `json:"qfmt"`
+	Afmt string `json:"afmt"`
+}
+
+func newAnkiModel(modelID, deckID int64) ankiModel {
+	return ankiModel{
+		ID:    modelID,
+		Name:  "gocards",
+		Did:   deckID,
+		Flds: []ankiField{
+			{Name: "Front", Ord: 0},
+			{Name: "Back", Ord: 1},
+			{Name: "Signature", Ord: 2},
+			{Name: "Code", Ord: 3},
+		},
+		Tmpls: []ankiTemplate{{
+			Name: "Card 1",
+			Qfmt: "{{Front}}",
+			Afmt: "{{FrontSide}}<hr id=answer>{{Back}}<pre>{{Signature}}</pre><pre>{{Code}}</pre>",
+		}},
+		CSS: ".card { font-family: arial; text-align: left; white-space: pre-wrap; }",
+		Req: [][]interface{}{{0, "any", []int{0}}},
+	}
+}
+
+// ankiDeck is one entry of the "decks" blob.
+type ankiDeck struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Mod  int64  `json:"mod"`
+	Usn  int    `json:"usn"`
+	Conf int64  `json:"conf"`
+	Dyn  int    `json:"dyn"`
+}
+
+func defaultAnkiDeck(id int64, name string) ankiDeck {
+	return ankiDeck{ID: id, Name: name, Conf: 1}
+}
+
+// ankiDeckConf is one entry of the "dconf" blob; Anki refuses to open a
+// collection whose decks reference a missing conf id, so even the
+// default deck needs one.
+type ankiDeckConf struct {
+	ID   int64 `json:"id"`
+	Name string `json:"name"`
+}
+
+func defaultAnkiDeckConf(id int64) ankiDeckConf {
+	return ankiDeckConf{ID: id, Name: "Default"}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/token"
+	"io"
+	"strings"
+)
+
+// Exporter writes flash cards for pkg directly to w, bypassing the
+// text/template pipeline. It's the extension point for output formats
+// that are better built from structured data than from a template, such
+// as the Anki .apkg deck format.
+type Exporter interface {
+	Export(fset *token.FileSet, pkg *doc.Package, w io.Writer) error
+}
+
+// card is the format-independent shape of one flash card, shared by every
+// Exporter.
+type card struct {
+	Symbol    string // exported identifier, e.g. "Foo" or "Foo.Bar"
+	Kind      string // "func", "type", "method", "const", or "var"
+	Front     string // the question
+	Back      string // the answer
+	Signature string // one-line signature, e.g. "func Foo(s string) error"
+	Code      string // full pretty-printed declaration
+}
+
+// exporterFor returns the Exporter for the given -format value, or nil if
+// format is handled by the template pipeline instead (quizlet, html, or
+// a user-supplied -tmpl).
+func exporterFor(format, deckName string) Exporter {
+	switch format {
+	case "anki":
+		return ankiExporter{deckName: deckName}
+	case "markdown":
+		return markdownExporter{}
+	case "json":
+		return jsonExporter{}
+	case "tsv":
+		return tsvExporter{}
+	default:
+		return nil
+	}
+}
+
+// cardsFromPackage flattens a doc.Package's exported funcs, types,
+// methods, consts, and vars into cards.
+func cardsFromPackage(fset *token.FileSet, pkg *doc.Package) ([]card, error) {
+	var cards []card
+
+	for _, fn := range pkg.Funcs {
+		if !isExported(fn.Decl) {
+			continue
+		}
+		sig, err := declString(fset, fn.Decl.Type)
+		if err != nil {
+			return nil, err
+		}
+		code, err := declString(fset, fn.Decl)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card{
+			Symbol:    fn.Name,
+			Kind:      "func",
+			Front:     fmt.Sprintf("What does function %s do and what is its declaration?", fn.Name),
+			Back:      firstSentence(fn.Doc),
+			Signature: strings.Replace(sig, "func", "func "+fn.Name, 1),
+			Code:      code,
+		})
+	}
+
+	for _, t := range pkg.Types {
+		if !isExported(t.Decl) {
+			continue
+		}
+		code, err := declString(fset, t.Decl)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card{
+			Symbol: t.Name,
+			Kind:   "type",
+			Front:  fmt.Sprintf("What is type %s?", t.Name),
+			Back:   firstSentence(t.Doc),
+			Code:   code,
+		})
+
+		for _, m := range t.Methods {
+			if !m.Decl.Name.IsExported() {
+				continue
+			}
+			sig, err := declString(fset, m.Decl.Type)
+			if err != nil {
+				return nil, err
+			}
+			code, err := declString(fset, m.Decl)
+			if err != nil {
+				return nil, err
+			}
+			cards = append(cards, card{
+				Symbol:    t.Name + "." + m.Name,
+				Kind:      "method",
+				Front:     fmt.Sprintf("What does method %s do and what is its declaration?", m.Name),
+				Back:      firstSentence(m.Doc),
+				Signature: strings.Replace(sig, "func", "func ("+m.Recv+") "+m.Name, 1),
+				Code:      code,
+			})
+		}
+	}
+
+	cards = append(cards, valueCards(fset, pkg.Consts, "const")...)
+	cards = append(cards, valueCards(fset, pkg.Vars, "var")...)
+
+	return cards, nil
+}
+
+// valueCards turns a package's Consts or Vars into one card per exported
+// name in the group (a const/var block can declare several names at
+// once), sharing that group's pretty-printed declaration as the code.
+func valueCards(fset *token.FileSet, vals []*doc.Value, kind string) []card {
+	var cards []card
+	for _, v := range vals {
+		if !isExported(v.Decl) {
+			continue
+		}
+		code, err := declString(fset, v.Decl)
+		if err != nil {
+			continue
+		}
+		for _, name := range v.Names {
+			if !ast.IsExported(name) {
+				continue
+			}
+			cards = append(cards, card{
+				Symbol: name,
+				Kind:   kind,
+				Front:  fmt.Sprintf("What is %s %s and what is its declaration?", kind, name),
+				Back:   firstSentence(v.Doc),
+				Code:   code,
+			})
+		}
+	}
+	return cards
+}
+
+// markdownExporter renders one "##" heading per card with a fenced ```go
+// code block for its declaration, the layout Mochi, Obsidian SR, and
+// similar spaced-repetition tools ingest directly.
+type markdownExporter struct{}
+
+func (markdownExporter) Export(fset *token.FileSet, pkg *doc.Package, w io.Writer) error {
+	cards, err := cardsFromPackage(fset, pkg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "# %s\n\n%s\n\n", pkg.Name, firstSentence(pkg.Doc))
+
+	for _, c := range cards {
+		fmt.Fprintf(w, "## %s\n\n%s\n\n", c.Symbol, c.Back)
+		if c.Code != "" {
+			fmt.Fprintf(w, "```go\n%s\n```\n\n", c.Code)
+		}
+	}
+
+	return nil
+}
+
+// jsonExporter writes the package's cards as a JSON array, one object per
+// card, for tools that'd rather parse structured data than Markdown.
+type jsonExporter struct{}
+
+func (jsonExporter) Export(fset *token.FileSet, pkg *doc.Package, w io.Writer) error {
+	cards, err := cardsFromPackage(fset, pkg)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cards)
+}
+
+// tsvExporter writes the package's cards as tab-separated Front/Back
+// columns, the format Anki's and Quizlet's "import from file" dialogs
+// both accept.
+type tsvExporter struct{}
+
+func (tsvExporter) Export(fset *token.FileSet, pkg *doc.Package, w io.Writer) error {
+	cards, err := cardsFromPackage(fset, pkg)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cards {
+		back := c.Back
+		if c.Code != "" {
+			code := strings.Replace(c.Code, "\n", "\\n", -1)
+			code = strings.Replace(code, "\t", "\\t", -1)
+			back += "\\n" + code
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", c.Front, back); err != nil {
+			return err
+		}
+	}
+	return nil
+}
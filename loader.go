@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// curTypesInfo and curTypesPkg hold the type-checker results for the
+// package currently being written, the same way pkgExamples holds its
+// examples. main repopulates them (to nil, in -src mode) before each
+// writePkgCards call so the "typeOf" and "methodSet" template funcs can
+// use them.
+var (
+	curTypesInfo *types.Info
+	curTypesPkg  *types.Package
+)
+
+// loadPackages resolves patterns like "./..." or "fmt encoding/json"
+// against the local module or GOPATH/stdlib, type-checking everything it
+// finds. All packages are parsed into the same fset so a single
+// writePkgCards pass can walk them one after another.
+func loadPackages(fset *token.FileSet, patterns []string) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports |
+			packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
+		Fset:  fset,
+		Tests: true,
+	}
+
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		return nil, fmt.Errorf("gocards: errors while loading %v", patterns)
+	}
+
+	return pkgs, nil
+}
+
+// astPackage reassembles the ast.Package that doc.New expects from the
+// *ast.File slice go/packages hands back.
+func astPackage(pkg *packages.Package, fset *token.FileSet) *ast.Package {
+	files := make(map[string]*ast.File, len(pkg.Syntax))
+	for _, f := range pkg.Syntax {
+		files[fset.Position(f.Package).Filename] = f
+	}
+	return &ast.Package{Name: pkg.Name, Files: files}
+}
+
+// isTestVariant reports whether pkg is one of the synthetic packages
+// cfg.Tests=true adds alongside each "plain" package: the internal variant
+// ("pkgpath [pkgpath.test]", which augments the plain package with its
+// in-package _test.go files) or the external black-box variant
+// ("pkgpath_test [pkgpath.test]"), or the generated test main package
+// ("pkgpath.test"). writePkgCards already gets the plain
+// package, so these must be skipped when iterating to avoid duplicate
+// cards; testSyntaxByPath mines them for Examples instead.
+func isTestVariant(pkg *packages.Package) bool {
+	return strings.Contains(pkg.ID, ".test]") || strings.HasSuffix(pkg.ID, ".test")
+}
+
+// testSyntaxByPath collects each package's _test.go syntax trees, keyed by
+// the import path of the plain (non-test-variant) package they belong to,
+// so writePkgCards can run doc.Examples over them without ever handing
+// them to doc.New.
+func testSyntaxByPath(fset *token.FileSet, pkgs []*packages.Package) map[string][]*ast.File {
+	files := make(map[string][]*ast.File)
+	for _, pkg := range pkgs {
+		if !isTestVariant(pkg) {
+			continue
+		}
+		base := strings.TrimSuffix(pkg.PkgPath, "_test")
+		for _, f := range pkg.Syntax {
+			if !strings.HasSuffix(fset.Position(f.Package).Filename, "_test.go") {
+				continue
+			}
+			files[base] = append(files[base], f)
+		}
+	}
+	return files
+}
+
+// typeOf reports the type-checked type of an expression, as computed by
+// the go/packages type-checking pass for the package currently being
+// written. It's "" outside loader mode (-src), where there is no
+// types.Info.
+func typeOf(n ast.Expr) string {
+	if curTypesInfo == nil {
+		return ""
+	}
+	if tv, ok := curTypesInfo.Types[n]; ok && tv.Type != nil {
+		return tv.Type.String()
+	}
+	return ""
+}
+
+// methodSet returns the names of the exported methods on the named
+// package-level type, including pointer-receiver methods, using the
+// type-checked types.Package for the package currently being written.
+func methodSet(name string) []string {
+	if curTypesPkg == nil {
+		return nil
+	}
+	obj := curTypesPkg.Scope().Lookup(name)
+	if obj == nil {
+		return nil
+	}
+
+	// A pointer to an interface has no methods, so interfaces use their
+	// own method set.
+	t := obj.Type()
+	if !types.IsInterface(t) {
+		t = types.NewPointer(t)
+	}
+	ms := types.NewMethodSet(t)
+	names := make([]string, 0, ms.Len())
+	for i := 0; i < ms.Len(); i++ {
+		if fn := ms.At(i).Obj(); fn.Exported() {
+			names = append(names, fn.Name())
+		}
+	}
+	return names
+}
@@ -6,14 +6,17 @@ import (
 	"fmt"
 	"go/ast"
 	"go/doc"
+	"go/format"
 	"go/parser"
 	"go/printer"
 	"go/token"
+	htmltemplate "html/template"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
-	"text/template"
+	texttemplate "text/template"
 )
 
 var (
@@ -21,66 +24,244 @@ var (
 	outDir    string
 	prefix    string
 	tmplFile  string
+	outFormat string
 	printTmpl bool
 )
 
+// pkgExamples holds the runnable examples for the package currently being
+// written, keyed by the symbol name they document (the part of the
+// Example name before the first underscore). It's repopulated by
+// writePkgCards before each template execution so the "examplesFor"
+// template func can look examples up by symbol name.
+var pkgExamples map[string][]*doc.Example
+
 // Flash card output template that works for Quizlet.
 var quizletTmpl = `What is pkg {{.Name}}?,{{firstSentence .Doc}};
 {{range .Funcs}}{{if isExported .Decl}}What does function {{.Name}} do and what is its declaration?,{{firstSentence .Doc}}
 
 {{funcDeclString .Decl}};
 {{end}}{{end}}
-{{range .Types}}{{if isExported .Decl}}What is type {{.Name}}?,{{firstSentence .Doc}};
+{{range .Types}}{{if isExported .Decl}}What is type {{.Name}}?,{{firstSentence .Doc}}
+
+{{typeDeclString .Decl}};
 {{range .Methods}}{{if .Decl.Name.IsExported}}What does method {{.Name}} do and what is its declaration?,{{firstSentence .Doc}}
 
 {{funcDeclString .Decl}};
 {{end}}{{end}}{{end}}{{end}}
+{{range .Consts}}{{if isExported .Decl}}{{$c := .}}{{range .Names}}{{if isExportedName .}}What is constant {{.}} and what is its declaration?,{{firstSentence $c.Doc}}
+
+{{constDeclString $c.Decl}};
+{{end}}{{end}}{{end}}{{end}}
+{{range .Vars}}{{if isExported .Decl}}{{$v := .}}{{range .Names}}{{if isExportedName .}}What is variable {{.}} and what is its declaration?,{{firstSentence $v.Doc}}
+
+{{varDeclString $v.Decl}};
+{{end}}{{end}}{{end}}{{end}}
+`
+
+// Flash card output template for "-format html". Unlike quizletTmpl, cards
+// are rendered as a self-contained HTML deck with show/hide toggles so a
+// learner can attempt to recall the answer before revealing it, the same
+// pattern godoc uses for example toggles.
+var htmlTmpl = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} flash cards</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; }
+.card { border: 1px solid #ccc; border-radius: 4px; margin-bottom: 1em; padding: 1em; }
+.front { cursor: pointer; font-weight: bold; }
+.back { display: none; }
+.back.expanded { display: block; }
+pre { background: #f6f6f6; padding: 0.5em; overflow-x: auto; }
+</style>
+<script>
+function toggle(id) {
+	var el = document.getElementById(id);
+	el.classList.toggle("collapsed");
+	el.classList.toggle("expanded");
+}
+</script>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+
+{{range .Funcs}}{{if isExported .Decl}}{{$name := .Name}}
+<div class="card">
+<div class="front" onclick="toggle('{{$name}}')">What does function {{$name}} do and what is its declaration?</div>
+<div id="{{$name}}" class="back collapsed">
+<p>{{firstSentence .Doc}}</p>
+<pre>{{funcDeclString .Decl}}</pre>
+</div>
+</div>
+{{range examplesFor $name}}
+<div class="card">
+<div class="front" onclick="toggle('{{.Name}}-example')">Show example usage of {{$name}}</div>
+<div id="{{.Name}}-example" class="back collapsed">
+<pre>{{exampleCode .Code}}</pre>
+{{if .Output}}<p>Output:</p>
+<pre>{{.Output}}</pre>{{end}}
+</div>
+</div>
+{{end}}{{end}}{{end}}
+
+{{range .Types}}{{if isExported .Decl}}{{$name := .Name}}
+<div class="card">
+<div class="front" onclick="toggle('{{$name}}')">What is type {{$name}}?</div>
+<div id="{{$name}}" class="back collapsed">
+<p>{{firstSentence .Doc}}</p>
+<pre>{{typeDeclString .Decl}}</pre>
+</div>
+</div>
+{{range examplesFor $name}}
+<div class="card">
+<div class="front" onclick="toggle('{{.Name}}-example')">Show example usage of {{$name}}</div>
+<div id="{{.Name}}-example" class="back collapsed">
+<pre>{{exampleCode .Code}}</pre>
+{{if .Output}}<p>Output:</p>
+<pre>{{.Output}}</pre>{{end}}
+</div>
+</div>
+{{end}}
+{{range .Methods}}{{if .Decl.Name.IsExported}}{{$mname := .Name}}
+<div class="card">
+<div class="front" onclick="toggle('{{$mname}}')">What does method {{$mname}} do and what is its declaration?</div>
+<div id="{{$mname}}" class="back collapsed">
+<p>{{firstSentence .Doc}}</p>
+<pre>{{funcDeclString .Decl}}</pre>
+</div>
+</div>
+{{end}}{{end}}{{end}}{{end}}
+
+{{range .Consts}}{{if isExported .Decl}}{{$c := .}}{{range .Names}}{{if isExportedName .}}
+<div class="card">
+<div class="front" onclick="toggle('{{.}}')">What is constant {{.}} and what is its declaration?</div>
+<div id="{{.}}" class="back collapsed">
+<p>{{firstSentence $c.Doc}}</p>
+<pre>{{constDeclString $c.Decl}}</pre>
+</div>
+</div>
+{{end}}{{end}}{{end}}{{end}}
+
+{{range .Vars}}{{if isExported .Decl}}{{$v := .}}{{range .Names}}{{if isExportedName .}}
+<div class="card">
+<div class="front" onclick="toggle('{{.}}')">What is variable {{.}} and what is its declaration?</div>
+<div id="{{.}}" class="back collapsed">
+<p>{{firstSentence $v.Doc}}</p>
+<pre>{{varDeclString $v.Decl}}</pre>
+</div>
+</div>
+{{end}}{{end}}{{end}}{{end}}
+
+</body>
+</html>
 `
 
 func main() {
-	flag.StringVar(&srcDir, "src", "", "Path to Go source code")
+	flag.StringVar(&srcDir, "src", "", "Path to Go source code (ignored if import path patterns are given)")
 	flag.StringVar(&outDir, "out", "", "Path to output directory")
 	flag.StringVar(&prefix, "prefix", "", "Prefix for output files")
 	flag.StringVar(&tmplFile, "tmpl", "", "Path to card template file")
+	flag.StringVar(&outFormat, "format", "quizlet", "Output format: quizlet, html, anki, markdown, json, or tsv")
 	flag.BoolVar(&printTmpl, "deftmpl", false, "Print default template to stdout and exit")
 	flag.Parse()
 
 	// If caller requested the default template, print it and exit.
 	if printTmpl {
-		fmt.Printf("%s\n", quizletTmpl)
+		fmt.Printf("%s\n", defaultTmpl(outFormat))
 		os.Exit(0)
 	}
 
-	// Parse the Go code in srcDir.
+	// Import path patterns on the command line (e.g. "./..." or "fmt
+	// encoding/json") switch to loader mode, which can walk a whole
+	// module or the standard library in one invocation. With no
+	// patterns, -src's single directory is parsed as before.
+	patterns := flag.Args()
+
 	fset := token.NewFileSet()
-	pkgs, err := parser.ParseDir(fset, srcDir, filterTests, parser.ParseComments)
-	check(err)
+	var err error
 
 	// Create a function that the Go template executor can use to get a
 	// string representation from a function declaration.
 	funcDeclString := func(f *ast.FuncDecl) (string, error) {
-		buf := &bytes.Buffer{}
-		if err := printer.Fprint(buf, fset, f); err != nil {
-			return "", err
-		}
-		return buf.String(), nil
+		return declString(fset, f)
 	}
 
-	// Helper functions that users can call in templates to extract
-	// data from docs and AST.
-	funcMap := template.FuncMap{
-		"firstSentence":  firstSentence,
-		"funcDeclString": funcDeclString,
-		"isExported":     isExported,
+	// exampleCode pretty-prints the Code of a doc.Example, the same way
+	// godoc renders the runnable source of an Example func.
+	exampleCode := func(n ast.Node) (string, error) {
+		return declString(fset, n)
 	}
 
-	// Parse the template that specifies the flash card output format.
-	tmpl := template.New("cards").Funcs(funcMap)
+	// constDeclString, varDeclString, and typeDeclString each pretty-print
+	// a *ast.GenDecl exactly as written, including any ( ... ) grouping
+	// and each spec's own doc comment, so a const/var/type block becomes
+	// one card per name but keeps the group's code intact.
+	genDeclString := func(d *ast.GenDecl) (string, error) {
+		return declString(fset, d)
+	}
+	constDeclString := genDeclString
+	varDeclString := genDeclString
+	typeDeclString := genDeclString
 
-	if tmplFile != "" {
-		tmpl, err = template.ParseFiles(tmplFile)
+	// examplesFor returns the examples documenting the symbol named name,
+	// as collected into pkgExamples for the package currently being
+	// written.
+	examplesFor := func(name string) []*doc.Example {
+		return pkgExamples[name]
+	}
+
+	// Helper functions that users can call in templates to extract data
+	// from docs and AST. It's a plain map, not a texttemplate.FuncMap or
+	// htmltemplate.FuncMap, so it converts to whichever template package
+	// -format needs.
+	funcMap := map[string]interface{}{
+		"firstSentence":   firstSentence,
+		"funcDeclString":  funcDeclString,
+		"isExported":      isExported,
+		"exampleCode":     exampleCode,
+		"examplesFor":     examplesFor,
+		"typeOf":          typeOf,
+		"methodSet":       methodSet,
+		"formatSource":    formatSource,
+		"isExportedName":  ast.IsExported,
+		"constDeclString": constDeclString,
+		"varDeclString":   varDeclString,
+		"typeDeclString":  typeDeclString,
+	}
+
+	// Parse the template that specifies the flash card output format.
+	// html/template is used for -format html instead of text/template
+	// since cards embed doc comments and pretty-printed source verbatim,
+	// and those routinely contain "<" or "&" that text/template would
+	// drop straight into the page as markup.
+	var tmpl cardTemplate
+	if outFormat == "html" {
+		if tmplFile != "" {
+			// Named after tmplFile's base, not "cards": ParseFiles
+			// defines each file as a template under its base name, and
+			// t must already have that name for Funcs to carry over
+			// into the parsed definition (the package-level
+			// htmltemplate.ParseFiles would build an unrelated
+			// *Template with no Funcs instead).
+			t := htmltemplate.New(filepath.Base(tmplFile)).Funcs(htmltemplate.FuncMap(funcMap))
+			t, err = t.ParseFiles(tmplFile)
+			tmpl = t
+		} else {
+			t := htmltemplate.New("cards").Funcs(htmltemplate.FuncMap(funcMap))
+			t, err = t.Parse(htmlTmpl)
+			tmpl = t
+		}
 	} else {
-		tmpl, err = tmpl.Parse(quizletTmpl)
+		if tmplFile != "" {
+			t := texttemplate.New(filepath.Base(tmplFile)).Funcs(texttemplate.FuncMap(funcMap))
+			t, err = t.ParseFiles(tmplFile)
+			tmpl = t
+		} else {
+			t := texttemplate.New("cards").Funcs(texttemplate.FuncMap(funcMap))
+			t, err = t.Parse(defaultTmpl(outFormat))
+			tmpl = t
+		}
 	}
 	check(err)
 
@@ -96,29 +277,78 @@ func main() {
 	check(err)
 
 	// Tell the user what's happening.
-	fmt.Printf("input: %s\n", srcDir)
 	fmt.Printf("output %s\n", outDir)
 	fmt.Println("generating...")
 
-	// Iterate through packages the parser found and generate flash
-	// cards for each using the output template.
-	for name, pkg := range pkgs {
-		if pkg.Name == "main" {
-			continue
+	if len(patterns) > 0 {
+		fmt.Printf("input: %s\n", strings.Join(patterns, " "))
+
+		pkgs, err := loadPackages(fset, patterns)
+		check(err)
+		testFiles := testSyntaxByPath(fset, pkgs)
+
+		for _, pkg := range pkgs {
+			if pkg.Name == "main" || isTestVariant(pkg) {
+				continue
+			}
+			curTypesInfo, curTypesPkg = pkg.TypesInfo, pkg.Types
+			err := writePkgCards(pkg.PkgPath, astPackage(pkg, fset), testFiles[pkg.PkgPath], fset, tmpl, prefix, outDir)
+			check(err)
 		}
-		err := writePkgCards(name, pkg, fset, tmpl, prefix, outDir)
+	} else {
+		fmt.Printf("input: %s\n", srcDir)
+
+		// doc.New must never see _test.go files (it would report
+		// TestXxx/ExampleXxx/BenchmarkXxx as ordinary package funcs), so
+		// they're parsed separately and only used for Example extraction.
+		pkgs, err := parser.ParseDir(fset, srcDir, filterTests, parser.ParseComments)
+		check(err)
+		testPkgs, err := parser.ParseDir(fset, srcDir, onlyTests, parser.ParseComments)
 		check(err)
+		testFiles := make(map[string][]*ast.File)
+		for tname, tpkg := range testPkgs {
+			base := strings.TrimSuffix(tname, "_test")
+			for _, f := range tpkg.Files {
+				testFiles[base] = append(testFiles[base], f)
+			}
+		}
+
+		for name, pkg := range pkgs {
+			if pkg.Name == "main" {
+				continue
+			}
+			curTypesInfo, curTypesPkg = nil, nil
+			err := writePkgCards(name, pkg, testFiles[name], fset, tmpl, prefix, outDir)
+			check(err)
+		}
 	}
 
 	fmt.Println("done")
 }
 
-func writePkgCards(name string, pkg *ast.Package, fset *token.FileSet, tmpl *template.Template, prefix, dir string) error {
+// cardTemplate is satisfied by both *texttemplate.Template and
+// *htmltemplate.Template, so writePkgCards doesn't need to know which
+// engine rendered -format's default (or -tmpl's custom) template.
+type cardTemplate interface {
+	Execute(wr io.Writer, data interface{}) error
+}
+
+func writePkgCards(name string, pkg *ast.Package, testFiles []*ast.File, fset *token.FileSet, tmpl cardTemplate, prefix, dir string) error {
 	// Each package will have its cards generated in a separate file named
-	// <prefix><package-name>.
+	// <prefix><package-name>, with an extension matching the format (none
+	// for quizlet or any other format formatExt doesn't know). In
+	// loader mode name is a full import path (e.g. "encoding/json"), so
+	// the file lands in a matching subdirectory of dir.
 	fname := fmt.Sprintf("%s%s", prefix, name)
+	if ext := formatExt(outFormat); ext != "" {
+		fname += ext
+	}
 	fname = filepath.Join(dir, fname)
 
+	if err := os.MkdirAll(filepath.Dir(fname), 0777); err != nil {
+		return err
+	}
+
 	// Create the output file for this package.
 	f, err := os.Create(fname)
 	if err != nil {
@@ -126,9 +356,27 @@ func writePkgCards(name string, pkg *ast.Package, fset *token.FileSet, tmpl *tem
 	}
 	defer f.Close()
 
+	// Collect the package's runnable Examples (ExampleFoo funcs in
+	// _test.go files) so examplesFor can find them by symbol name. This
+	// has to happen before doc.New, and from files doc.New never sees:
+	// doc.New takes ownership of pkg and may overwrite its AST, and pkg
+	// here never includes _test.go files in the first place (doc.New
+	// would otherwise report TestXxx/ExampleXxx/BenchmarkXxx as ordinary
+	// package funcs).
+	pkgExamples = exampleMap(doc.Examples(testFiles...))
+
 	// Get the docs for this package from the AST.
 	p := doc.New(pkg, "", doc.AllDecls|doc.AllMethods)
 
+	// quizlet and html stay template-driven so -tmpl keeps working; the
+	// other formats are structured enough to export directly.
+	if exp := exporterFor(outFormat, prefix+name); exp != nil {
+		// doc.New was given no import path; the Anki exporter keys note
+		// GUIDs on it.
+		p.ImportPath = name
+		return exp.Export(fset, p, f)
+	}
+
 	// Execute the template to write flash cards for this package to
 	// the output file.
 	if err := tmpl.Execute(f, p); err != nil {
@@ -138,6 +386,72 @@ func writePkgCards(name string, pkg *ast.Package, fset *token.FileSet, tmpl *tem
 	return nil
 }
 
+// declString pretty-prints an AST node (a *ast.FuncDecl, *ast.GenDecl, or
+// an Example's Code) using the given FileSet, then runs the result
+// through formatSource so stale indentation from printing a node outside
+// its original file doesn't leak into cards.
+func declString(fset *token.FileSet, n ast.Node) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := printer.Fprint(buf, fset, n); err != nil {
+		return "", err
+	}
+	return formatSource(buf.String()), nil
+}
+
+// formatSource runs src through go/format.Source, the same formatting
+// gofmt applies, falling back to src unchanged if it doesn't parse on its
+// own (format.Source accepts a full file, or a single declaration,
+// statement list, or expression, but not arbitrary fragments).
+func formatSource(src string) string {
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return src
+	}
+	return string(formatted)
+}
+
+// formatExt returns the file extension writePkgCards should use for a
+// given -format value, or "" if the format doesn't imply one.
+func formatExt(format string) string {
+	switch format {
+	case "html":
+		return ".html"
+	case "anki":
+		return ".apkg"
+	case "markdown":
+		return ".md"
+	case "json":
+		return ".json"
+	case "tsv":
+		return ".tsv"
+	default:
+		return ""
+	}
+}
+
+// exampleMap groups examples by the symbol name they document, e.g.
+// ExampleFoo and ExampleFoo_Bar both document the symbol "Foo".
+func exampleMap(examples []*doc.Example) map[string][]*doc.Example {
+	m := make(map[string][]*doc.Example)
+	for _, ex := range examples {
+		name := ex.Name
+		if i := strings.Index(name, "_"); i > 0 {
+			name = name[:i]
+		}
+		m[name] = append(m[name], ex)
+	}
+	return m
+}
+
+// defaultTmpl returns the built-in card template for the given output
+// format.
+func defaultTmpl(format string) string {
+	if format == "html" {
+		return htmlTmpl
+	}
+	return quizletTmpl
+}
+
 func firstSentence(paragraph string) string {
 	sentences := strings.Split(paragraph, ".")
 	sentence := trim(sentences[0]) + "."
@@ -148,15 +462,27 @@ func isExported(n ast.Node) bool {
 	switch t := n.(type) {
 	case *ast.GenDecl:
 		switch t.Tok {
-		case token.TYPE:
-			if len(t.Specs) < 1 {
-				return false
+		case token.TYPE, token.CONST, token.VAR:
+			// A grouped const/var block can mix exported and unexported
+			// names in one decl (doc.New doesn't split them), so the
+			// group counts as exported if ANY of its specs does -
+			// gating on just Specs[0] would drop every exported name
+			// that happens to follow an unexported one in source order.
+			for _, spec := range t.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.IsExported() {
+						return true
+					}
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						if name.IsExported() {
+							return true
+						}
+					}
+				}
 			}
-			ts, ok := t.Specs[0].(*ast.TypeSpec)
-			if !ok {
-				return false
-			}
-			return ts.Name.IsExported()
+			return false
 		default:
 			return false
 		}
@@ -171,6 +497,14 @@ func filterTests(fi os.FileInfo) bool {
 	return !strings.Contains(fi.Name(), "_test")
 }
 
+// onlyTests is filterTests inverted: it's the parser.ParseDir filter used
+// to collect _test.go files separately, so their ExampleFoo funcs can feed
+// doc.Examples without their TestFoo/BenchmarkFoo funcs ever reaching
+// doc.New.
+func onlyTests(fi os.FileInfo) bool {
+	return !filterTests(fi)
+}
+
 func trim(s string) string {
 	s = strings.TrimSpace(s)
 	s = strings.Replace(s, "\n", " ", -1)